@@ -0,0 +1,346 @@
+// Package cockroach implements patchain.DB on top of CockroachDB using gorm.
+package cockroach
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ellcrys/gorm"
+	"github.com/ellcrys/patchain"
+	"github.com/ellcrys/patchain/cockroach/tables"
+	"github.com/ellcrys/patchain/driver"
+)
+
+// blacklistedFields are object columns that must never be referenced
+// directly in a caller-supplied query (they are either internal bookkeeping
+// columns or are otherwise unsafe to filter/sort by).
+var blacklistedFields = []string{"Hash", "PeerHash"}
+
+// DB is a gorm-backed implementation of patchain.DB. It defaults to talking
+// to CockroachDB, but can be pointed at any backend with a driver.Dialect.
+type DB struct {
+	ConnectionString string
+	db               *gorm.DB
+	logging          bool
+	readOnly         bool
+	dialect          driver.Dialect
+}
+
+// NewDB creates a new, unconnected DB. dialect configures the backend to
+// connect to and its SQL quirks; it defaults to driver.CockroachDialect for
+// backward compatibility with existing callers.
+func NewDB(dialect ...driver.Dialect) *DB {
+	return &DB{logging: true, dialect: dialectOrDefault(dialect)}
+}
+
+// dialectOrDefault returns dialects[0] if present and non-nil, otherwise
+// driver.CockroachDialect{}.
+func dialectOrDefault(dialects []driver.Dialect) driver.Dialect {
+	if len(dialects) > 0 && dialects[0] != nil {
+		return dialects[0]
+	}
+	return driver.CockroachDialect{}
+}
+
+// getDialect returns cdb.dialect, or driver.CockroachDialect{} if cdb was
+// built by struct literal (e.g. in tests) rather than through NewDB and so
+// never had one set.
+func (cdb *DB) getDialect() driver.Dialect {
+	return dialectOrDefault([]driver.Dialect{cdb.dialect})
+}
+
+// NewDB returns a new, unconnected DB sharing the receiver's configuration.
+func (cdb *DB) NewDB() patchain.DB {
+	return &DB{ConnectionString: cdb.ConnectionString, logging: cdb.logging, dialect: cdb.getDialect()}
+}
+
+// NoLogging disables query logging.
+func (cdb *DB) NoLogging() {
+	cdb.logging = false
+}
+
+// Connect opens the connection pool to the database described by
+// cdb.ConnectionString, using cdb.dialect's driver.
+func (cdb *DB) Connect(maxOpenConns, maxIdleConns int) error {
+	db, err := cdb.getDialect().Open(cdb.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %s", err)
+	}
+	db.DB().SetMaxOpenConns(maxOpenConns)
+	db.DB().SetMaxIdleConns(maxIdleConns)
+	db.LogMode(cdb.logging)
+	cdb.db = db
+	return cdb.AssertSchemaCompatible()
+}
+
+// GetConn returns the underlying *gorm.DB connection.
+func (cdb *DB) GetConn() interface{} {
+	return cdb.db
+}
+
+// SetConn replaces the underlying connection. conn must be a *gorm.DB.
+func (cdb *DB) SetConn(conn interface{}) error {
+	db, ok := conn.(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("connection type not supported. Requires *gorm.DB")
+	}
+	cdb.db = db
+	return nil
+}
+
+// GetValidObjectFields returns the set of tables.Object column names that
+// are safe to reference in a query (i.e. not blacklisted).
+func (cdb *DB) GetValidObjectFields() []string {
+	var fields []string
+	scope := cdb.db.NewScope(&tables.Object{})
+	for _, field := range scope.Fields() {
+		if !contains(blacklistedFields, field.Name) {
+			fields = append(fields, field.DBName)
+		}
+	}
+	return fields
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// getDBTxFromOption returns the DB handle to use for an operation: the one
+// carried by a patchain.UseDBOption in opts if present, otherwise fallback.
+// The second return value reports whether the caller is responsible for
+// finishing (committing/rolling back) the returned handle.
+func (cdb *DB) getDBTxFromOption(opts []patchain.Option, fallback patchain.DB) (patchain.DB, bool) {
+	for _, opt := range opts {
+		if useDB, ok := opt.(*patchain.UseDBOption); ok {
+			return useDB.DB, useDB.Finish
+		}
+	}
+	return fallback, false
+}
+
+// resolveDB returns the DB to issue a query/statement against, resolving a
+// patchain.UseDBOption if one was passed in opts. A target handed in via
+// UseDBOption that was built by struct literal rather than NewDB (as the
+// getDBTxFromOption tests do) has no dialect of its own, so it borrows the
+// receiver's without mutating the caller's value.
+func (cdb *DB) resolveDB(opts []patchain.Option) *DB {
+	db, _ := cdb.getDBTxFromOption(opts, cdb)
+	target := db.(*DB)
+	if target.dialect == nil {
+		borrowed := *target
+		borrowed.dialect = cdb.getDialect()
+		return &borrowed
+	}
+	return target
+}
+
+// connOf returns the *gorm.DB to issue a query/statement against, resolving
+// a patchain.UseDBOption if one was passed in opts.
+func (cdb *DB) connOf(opts []patchain.Option) *gorm.DB {
+	return cdb.resolveDB(opts).db
+}
+
+// Begin starts a new transaction and returns a DB bound to it.
+func (cdb *DB) Begin() patchain.DB {
+	return &DB{ConnectionString: cdb.ConnectionString, logging: cdb.logging, dialect: cdb.getDialect(), db: cdb.db.Begin()}
+}
+
+// Commit commits the transaction the receiver is bound to.
+func (cdb *DB) Commit() error {
+	return cdb.db.Commit().Error
+}
+
+// Rollback rolls back the transaction the receiver is bound to.
+func (cdb *DB) Rollback() error {
+	return cdb.db.Rollback().Error
+}
+
+// Create persists a new object.
+func (cdb *DB) Create(obj interface{}, opts ...patchain.Option) error {
+	target := cdb.resolveDB(opts)
+	if target.readOnly {
+		return patchain.ErrReadOnly
+	}
+	return target.db.Create(obj).Error
+}
+
+// CreateBulk persists multiple new objects, using target's dialect to
+// batch the inserts as well as it is able to.
+func (cdb *DB) CreateBulk(objs []interface{}, opts ...patchain.Option) error {
+	target := cdb.resolveDB(opts)
+	if target.readOnly {
+		return patchain.ErrReadOnly
+	}
+	return target.dialect.BulkInsert(target.db, objs)
+}
+
+// TransactWithDB runs fn within the transaction already open on dbTx. If
+// finishTx is true, the transaction is committed when fn returns nil, or
+// rolled back otherwise. If opts carries a patchain.RetryOnConflict option,
+// a run that fails with a retryable serialization conflict is rolled back
+// and retried from a fresh Begin, with an exponential backoff, instead of
+// returning the error.
+func (cdb *DB) TransactWithDB(dbTx patchain.DB, finishTx bool, fn patchain.TxFunc, opts ...patchain.Option) error {
+
+	maxAttempts, retry := retryAttemptsOf(opts)
+	if retry && maxAttempts <= 0 {
+		maxAttempts = defaultMaxRetryAttempts
+	}
+
+	backoff := initialRetryBackoff
+	var lastErr error
+
+	for attempt := 0; !retry || attempt < maxAttempts; attempt++ {
+
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			dbTx = cdb.Begin()
+		}
+
+		committed := false
+		rolledBack := false
+
+		commit := func() error {
+			committed = true
+			return dbTx.Commit()
+		}
+
+		rollback := func() error {
+			rolledBack = true
+			return dbTx.Rollback()
+		}
+
+		err := fn(dbTx, commit, rollback)
+
+		if finishTx && !committed && !rolledBack {
+			if err != nil {
+				dbTx.Rollback()
+			} else {
+				err = dbTx.Commit()
+			}
+		}
+
+		if err != nil && retry && cdb.getDialect().IsRetryable(err) {
+			if !finishTx && !committed && !rolledBack {
+				dbTx.Rollback()
+			}
+			lastErr = err
+			continue
+		}
+
+		return err
+	}
+
+	return lastErr
+}
+
+// UpdatePeerHash sets the peer hash of the object matching query.
+func (cdb *DB) UpdatePeerHash(query interface{}, peerHash string, opts ...patchain.Option) error {
+	target := cdb.resolveDB(opts)
+	if target.readOnly {
+		return patchain.ErrReadOnly
+	}
+	return target.db.Model(query).Where(query).Update("peer_hash", peerHash).Error
+}
+
+// GetLast populates dst with the last object matching query, ordered by
+// primary key (ID) unless query's QueryParams set an OrderBy. It returns
+// patchain.ErrNotFound if nothing matched.
+func (cdb *DB) GetLast(query, dst interface{}, opts ...patchain.Option) error {
+	conn := cdb.connOf(opts)
+	modifiers, err := cdb.getQueryModifiers(query)
+	if err != nil {
+		return err
+	}
+	err = conn.Scopes(modifiers...).Last(dst).Error
+	if err == gorm.ErrRecordNotFound {
+		return patchain.ErrNotFound
+	}
+	return err
+}
+
+// GetAll populates dst with all objects matching query.
+func (cdb *DB) GetAll(query, dst interface{}, opts ...patchain.Option) error {
+	conn := cdb.connOf(opts)
+	modifiers, err := cdb.getQueryModifiers(query)
+	if err != nil {
+		return err
+	}
+	return conn.Scopes(modifiers...).Find(dst).Error
+}
+
+// Count sets dst to the number of objects matching query.
+func (cdb *DB) Count(query interface{}, dst *int64, opts ...patchain.Option) error {
+	conn := cdb.connOf(opts)
+	modifiers, err := cdb.getQueryModifiers(query)
+	if err != nil {
+		return err
+	}
+	var count int
+	err = conn.Model(query).Scopes(modifiers...).Count(&count).Error
+	*dst = int64(count)
+	return err
+}
+
+// getQueryModifiers translates the patchain.QueryParams embedded in query
+// (if any) into gorm scope functions. It returns an error if query carries a
+// FieldFilter referencing a field that isn't in GetValidObjectFields, or an
+// unsupported/malformed filter.
+func (cdb *DB) getQueryModifiers(query interface{}) ([]func(*gorm.DB) *gorm.DB, error) {
+
+	var modifiers []func(*gorm.DB) *gorm.DB
+
+	getter, ok := query.(patchain.QueryParamsGetter)
+	if !ok {
+		modifiers = append(modifiers, func(db *gorm.DB) *gorm.DB {
+			return db.Where(query)
+		})
+		return modifiers, nil
+	}
+
+	params := getter.GetQueryParams()
+
+	if params.Expr.Expr != "" {
+		modifiers = append(modifiers, func(db *gorm.DB) *gorm.DB {
+			return db.Where(params.Expr.Expr, params.Expr.Args...)
+		})
+	} else {
+		modifiers = append(modifiers, func(db *gorm.DB) *gorm.DB {
+			return db.Where(query)
+		})
+	}
+
+	if params.KeyStartsWith != "" {
+		modifiers = append(modifiers, func(db *gorm.DB) *gorm.DB {
+			return db.Where("key LIKE ?", params.KeyStartsWith+"%")
+		})
+	}
+
+	for _, filter := range params.Filters {
+		scope, err := cdb.buildFilterScope(filter)
+		if err != nil {
+			return nil, err
+		}
+		modifiers = append(modifiers, scope)
+	}
+
+	if params.OrderBy != "" {
+		modifiers = append(modifiers, func(db *gorm.DB) *gorm.DB {
+			return db.Order(params.OrderBy)
+		})
+	}
+
+	if params.Limit > 0 {
+		modifiers = append(modifiers, func(db *gorm.DB) *gorm.DB {
+			return db.Limit(params.Limit)
+		})
+	}
+
+	return modifiers, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/ellcrys/gorm"
 	"github.com/ellcrys/patchain"
 	"github.com/ellcrys/patchain/cockroach/tables"
+	"github.com/ellcrys/patchain/driver"
 	"github.com/ellcrys/util"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	. "github.com/smartystreets/goconvey/convey"
@@ -39,7 +40,7 @@ func dropDB(t *testing.T) error {
 }
 
 func clearTable(db *gorm.DB, tables ...string) error {
-	_, err := db.CommonDB().Exec("TRUNCATE " + strings.Join(tables, ","))
+	_, err := db.CommonDB().Exec(driver.CockroachDialect{}.TruncateSQL(strings.Join(tables, ",")))
 	if err != nil {
 		return err
 	}
@@ -374,11 +375,12 @@ func TestCockroach(t *testing.T) {
 					err := cdb.Create(obj)
 					So(err, ShouldBeNil)
 					conn := cdb.GetConn().(*gorm.DB)
-					modifiers := cdb.getQueryModifiers(&tables.Object{
+					modifiers, err := cdb.getQueryModifiers(&tables.Object{
 						QueryParams: patchain.QueryParams{
 							KeyStartsWith: "special_key_prefix",
 						},
 					})
+					So(err, ShouldBeNil)
 					var last tables.Object
 					err = conn.Scopes(modifiers...).Last(&last).Error
 					So(err, ShouldBeNil)
@@ -396,11 +398,12 @@ func TestCockroach(t *testing.T) {
 					err := cdb.CreateBulk(objsI)
 					So(err, ShouldBeNil)
 					conn := cdb.GetConn().(*gorm.DB)
-					modifiers := cdb.getQueryModifiers(&tables.Object{
+					modifiers, err := cdb.getQueryModifiers(&tables.Object{
 						QueryParams: patchain.QueryParams{
 							OrderBy: "key desc",
 						},
 					})
+					So(err, ShouldBeNil)
 					var res []*tables.Object
 					err = conn.Scopes(modifiers...).Find(&res).Error
 					So(err, ShouldBeNil)
@@ -409,11 +412,12 @@ func TestCockroach(t *testing.T) {
 					So(res[1], ShouldResemble, objs[0])
 
 					res = []*tables.Object{}
-					modifiers = cdb.getQueryModifiers(&tables.Object{
+					modifiers, err = cdb.getQueryModifiers(&tables.Object{
 						QueryParams: patchain.QueryParams{
 							OrderBy: "key desc",
 						},
 					})
+					So(err, ShouldBeNil)
 					err = conn.NewScope(nil).DB().Scopes(modifiers...).Find(&res).Error
 					So(err, ShouldBeNil)
 					So(len(objs), ShouldEqual, 2)
@@ -428,7 +432,7 @@ func TestCockroach(t *testing.T) {
 					So(err, ShouldBeNil)
 					conn := cdb.GetConn().(*gorm.DB)
 					res := []*tables.Object{}
-					modifiers := cdb.getQueryModifiers(&tables.Object{
+					modifiers, err := cdb.getQueryModifiers(&tables.Object{
 						Key: "some_key",
 						QueryParams: patchain.QueryParams{
 							Expr: patchain.Expr{
@@ -437,6 +441,7 @@ func TestCockroach(t *testing.T) {
 							},
 						},
 					})
+					So(err, ShouldBeNil)
 					err = conn.NewScope(nil).DB().Scopes(modifiers...).Find(&res).Error
 					So(err, ShouldBeNil)
 					So(len(res), ShouldEqual, 1)
@@ -454,12 +459,13 @@ func TestCockroach(t *testing.T) {
 					err := cdb.CreateBulk(objsI)
 					So(err, ShouldBeNil)
 					conn := cdb.GetConn().(*gorm.DB)
-					modifiers := cdb.getQueryModifiers(&tables.Object{
+					modifiers, err := cdb.getQueryModifiers(&tables.Object{
 						QueryParams: patchain.QueryParams{
 							Limit:   1,
 							OrderBy: "timestamp desc",
 						},
 					})
+					So(err, ShouldBeNil)
 					var res []*tables.Object
 					err = conn.Scopes(modifiers...).Find(&res).Error
 					So(err, ShouldBeNil)
@@ -471,6 +477,44 @@ func TestCockroach(t *testing.T) {
 					clearTable(cdb.GetConn().(*gorm.DB), "objects")
 				})
 			})
+
+			Convey("Filters", func() {
+				Convey("Should apply a contains filter", func() {
+					obj := &tables.Object{ID: util.UUID4(), Key: "prefix_" + util.RandString(5) + "_suffix", PeerHash: util.RandString(5), PrevHash: util.RandString(5)}
+					obj.Init().ComputeHash()
+					err := cdb.Create(obj)
+					So(err, ShouldBeNil)
+					conn := cdb.GetConn().(*gorm.DB)
+					modifiers, err := cdb.getQueryModifiers(&tables.Object{
+						QueryParams: patchain.QueryParams{
+							Filters: []patchain.FieldFilter{
+								{Field: "key", Op: patchain.FilterContains, Value: "suffix"},
+							},
+						},
+					})
+					So(err, ShouldBeNil)
+					var res []*tables.Object
+					err = conn.Scopes(modifiers...).Find(&res).Error
+					So(err, ShouldBeNil)
+					So(len(res), ShouldEqual, 1)
+					So(res[0], ShouldResemble, obj)
+				})
+
+				Convey("Should return an error when the field is not whitelisted", func() {
+					_, err := cdb.getQueryModifiers(&tables.Object{
+						QueryParams: patchain.QueryParams{
+							Filters: []patchain.FieldFilter{
+								{Field: "hash", Op: patchain.FilterExact, Value: "x"},
+							},
+						},
+					})
+					So(err, ShouldNotBeNil)
+				})
+
+				Reset(func() {
+					clearTable(cdb.GetConn().(*gorm.DB), "objects")
+				})
+			})
 		})
 	})
 }
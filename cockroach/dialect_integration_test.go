@@ -0,0 +1,114 @@
+//go:build dialect_integration
+// +build dialect_integration
+
+// This file exercises the same cockroach.DB wiring the rest of this
+// package's tests exercise against CockroachDB — CreateTables (migrations
+// plus applyDialectSchema), CreateBulk, the ILIKE fallback in filterSQL, and
+// retry classification — against the other three driver.Dialect
+// implementations. It's gated behind the dialect_integration build tag
+// because most environments, including this repo's default test run, don't
+// have Postgres, MySQL and SQLite servers available alongside CockroachDB:
+//
+//	PATCHAIN_TEST_POSTGRES_DSN=... \
+//	PATCHAIN_TEST_MYSQL_DSN=... \
+//	PATCHAIN_TEST_SQLITE_DSN=... \
+//	  go test -tags dialect_integration ./cockroach/...
+//
+// A dialect whose DSN env var isn't set has its Convey block skipped.
+package cockroach
+
+import (
+	"os"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	"github.com/ellcrys/patchain"
+	"github.com/ellcrys/patchain/cockroach/tables"
+	"github.com/ellcrys/patchain/driver"
+	"github.com/ellcrys/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// exerciseDialect runs the same sequence of operations cockroach.DB's other
+// tests run against CockroachDB, against dialect connected via dsn, and
+// checks a dialect-specific error with isRetryableErr.
+func exerciseDialect(dialect driver.Dialect, dsn string, isRetryableErr error) {
+	cdb := NewDB(dialect)
+	cdb.ConnectionString = dsn
+	cdb.NoLogging()
+
+	Convey("Should connect and create tables", func() {
+		So(cdb.Connect(0, 5), ShouldBeNil)
+		So(cdb.CreateTables(), ShouldBeNil)
+	})
+
+	Convey("Should bulk-create and case-insensitively filter objects", func() {
+		So(cdb.Connect(0, 5), ShouldBeNil)
+		So(cdb.CreateTables(), ShouldBeNil)
+
+		objs := []interface{}{
+			(&tables.Object{ID: util.UUID4(), Key: "Alpha_" + util.RandString(5)}).Init().ComputeHash(),
+			(&tables.Object{ID: util.UUID4(), Key: "Beta_" + util.RandString(5)}).Init().ComputeHash(),
+		}
+		So(cdb.CreateBulk(objs), ShouldBeNil)
+
+		var res []tables.Object
+		err := cdb.GetAll(&tables.Object{
+			QueryParams: patchain.QueryParams{
+				Filters: []patchain.FieldFilter{
+					{Field: "key", Op: patchain.FilterIContains, Value: "ALPHA"},
+				},
+			},
+		}, &res)
+		So(err, ShouldBeNil)
+		So(len(res), ShouldEqual, 1)
+	})
+
+	Convey("Should classify the dialect's own conflict error as retryable", func() {
+		So(dialect.IsRetryable(isRetryableErr), ShouldBeTrue)
+		So(dialect.IsRetryable(nil), ShouldBeFalse)
+	})
+}
+
+func TestDialectIntegration(t *testing.T) {
+	Convey("Dialect wiring", t, func() {
+
+		Convey("Postgres", func() {
+			dsn := os.Getenv("PATCHAIN_TEST_POSTGRES_DSN")
+			if dsn == "" {
+				SkipConvey("PATCHAIN_TEST_POSTGRES_DSN not set", func() {})
+				return
+			}
+			exerciseDialect(driver.PostgresDialect{}, dsn, &pq.Error{Code: "40001"})
+		})
+
+		Convey("MySQL", func() {
+			dsn := os.Getenv("PATCHAIN_TEST_MYSQL_DSN")
+			if dsn == "" {
+				SkipConvey("PATCHAIN_TEST_MYSQL_DSN not set", func() {})
+				return
+			}
+			exerciseDialect(driver.MySQLDialect{}, dsn, &mysqldriver.MySQLError{Number: 1213})
+		})
+
+		Convey("SQLite", func() {
+			dsn := os.Getenv("PATCHAIN_TEST_SQLITE_DSN")
+			if dsn == "" {
+				SkipConvey("PATCHAIN_TEST_SQLITE_DSN not set", func() {})
+				return
+			}
+			exerciseDialect(driver.SQLiteDialect{}, dsn, errLocked)
+		})
+	})
+}
+
+// errLocked mimics the error go-sqlite3 returns for SQLITE_BUSY/LOCKED,
+// which SQLiteDialect.IsRetryable matches on message substring rather than
+// a typed error (go-sqlite3 doesn't export one).
+type errLockedType struct{}
+
+func (errLockedType) Error() string { return "database is locked" }
+
+var errLocked = errLockedType{}
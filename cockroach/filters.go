@@ -0,0 +1,103 @@
+package cockroach
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ellcrys/gorm"
+	"github.com/ellcrys/patchain"
+	"github.com/ellcrys/patchain/driver"
+)
+
+// filterSQL translates a single patchain.FieldFilter into a parameterized
+// SQL fragment and its bind arguments. dialect is used to quote the column
+// identifier and to pick a case-insensitive LIKE strategy the backend
+// actually supports, and is injectable for testing.
+func filterSQL(filter patchain.FieldFilter, dialect driver.Dialect) (string, []interface{}, error) {
+
+	col := dialect.QuoteIdent(filter.Field)
+
+	ilike := func(pattern string) (string, []interface{}) {
+		if dialect.SupportsILike() {
+			return col + " ILIKE ?", []interface{}{pattern}
+		}
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", col), []interface{}{pattern}
+	}
+
+	switch filter.Op {
+	case patchain.FilterExact, "":
+		return col + " = ?", []interface{}{filter.Value}, nil
+	case patchain.FilterIExact:
+		expr, args := ilike(fmt.Sprintf("%v", filter.Value))
+		return expr, args, nil
+	case patchain.FilterContains:
+		return col + " LIKE ?", []interface{}{fmt.Sprintf("%%%v%%", filter.Value)}, nil
+	case patchain.FilterIContains:
+		expr, args := ilike(fmt.Sprintf("%%%v%%", filter.Value))
+		return expr, args, nil
+	case patchain.FilterStartsWith:
+		return col + " LIKE ?", []interface{}{fmt.Sprintf("%v%%", filter.Value)}, nil
+	case patchain.FilterIStartsWith:
+		expr, args := ilike(fmt.Sprintf("%v%%", filter.Value))
+		return expr, args, nil
+	case patchain.FilterEndsWith:
+		return col + " LIKE ?", []interface{}{fmt.Sprintf("%%%v", filter.Value)}, nil
+	case patchain.FilterIEndsWith:
+		expr, args := ilike(fmt.Sprintf("%%%v", filter.Value))
+		return expr, args, nil
+	case patchain.FilterGT:
+		return col + " > ?", []interface{}{filter.Value}, nil
+	case patchain.FilterGTE:
+		return col + " >= ?", []interface{}{filter.Value}, nil
+	case patchain.FilterLT:
+		return col + " < ?", []interface{}{filter.Value}, nil
+	case patchain.FilterLTE:
+		return col + " <= ?", []interface{}{filter.Value}, nil
+	case patchain.FilterIn:
+		return col + " IN (?)", []interface{}{filter.Value}, nil
+	case patchain.FilterIsNull:
+		isNull, _ := filter.Value.(bool)
+		if isNull {
+			return col + " IS NULL", nil, nil
+		}
+		return col + " IS NOT NULL", nil, nil
+	case patchain.FilterBetween:
+		bounds, err := betweenBounds(filter.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("between filter on %q: %s", filter.Field, err)
+		}
+		return col + " BETWEEN ? AND ?", bounds, nil
+	default:
+		return "", nil, fmt.Errorf("unknown filter operator %q", filter.Op)
+	}
+}
+
+// betweenBounds extracts the two bind arguments for a FilterBetween filter
+// from value, which may be any concrete slice type (e.g. []int, []string,
+// []time.Time), not just []interface{}.
+func betweenBounds(value interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice || v.Len() != 2 {
+		return nil, fmt.Errorf("requires a 2-element slice value")
+	}
+	return []interface{}{v.Index(0).Interface(), v.Index(1).Interface()}, nil
+}
+
+// buildFilterScope validates filter against the backend's whitelist of
+// queryable fields and returns a gorm scope applying it, or an error if the
+// field is not allowed or the operator/value is invalid.
+func (cdb *DB) buildFilterScope(filter patchain.FieldFilter) (func(*gorm.DB) *gorm.DB, error) {
+
+	if !contains(cdb.GetValidObjectFields(), filter.Field) {
+		return nil, fmt.Errorf("field %q is not a valid query field", filter.Field)
+	}
+
+	expr, args, err := filterSQL(filter, cdb.getDialect())
+	if err != nil {
+		return nil, err
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(expr, args...)
+	}, nil
+}
@@ -0,0 +1,80 @@
+package cockroach
+
+import (
+	"testing"
+
+	"github.com/ellcrys/patchain"
+	"github.com/ellcrys/patchain/driver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilterSQL(t *testing.T) {
+	Convey("filterSQL", t, func() {
+
+		crdb := driver.CockroachDialect{}
+
+		cases := []struct {
+			desc     string
+			filter   patchain.FieldFilter
+			wantExpr string
+			wantArgs []interface{}
+		}{
+			{"exact", patchain.FieldFilter{Field: "key", Op: patchain.FilterExact, Value: "a"}, `"key" = ?`, []interface{}{"a"}},
+			{"default op is exact", patchain.FieldFilter{Field: "key", Value: "a"}, `"key" = ?`, []interface{}{"a"}},
+			{"iexact", patchain.FieldFilter{Field: "key", Op: patchain.FilterIExact, Value: "a"}, `"key" ILIKE ?`, []interface{}{"a"}},
+			{"contains", patchain.FieldFilter{Field: "key", Op: patchain.FilterContains, Value: "a"}, `"key" LIKE ?`, []interface{}{"%a%"}},
+			{"icontains", patchain.FieldFilter{Field: "key", Op: patchain.FilterIContains, Value: "a"}, `"key" ILIKE ?`, []interface{}{"%a%"}},
+			{"startswith", patchain.FieldFilter{Field: "key", Op: patchain.FilterStartsWith, Value: "a"}, `"key" LIKE ?`, []interface{}{"a%"}},
+			{"istartswith", patchain.FieldFilter{Field: "key", Op: patchain.FilterIStartsWith, Value: "a"}, `"key" ILIKE ?`, []interface{}{"a%"}},
+			{"endswith", patchain.FieldFilter{Field: "key", Op: patchain.FilterEndsWith, Value: "a"}, `"key" LIKE ?`, []interface{}{"%a"}},
+			{"iendswith", patchain.FieldFilter{Field: "key", Op: patchain.FilterIEndsWith, Value: "a"}, `"key" ILIKE ?`, []interface{}{"%a"}},
+			{"gt", patchain.FieldFilter{Field: "timestamp", Op: patchain.FilterGT, Value: 1}, `"timestamp" > ?`, []interface{}{1}},
+			{"gte", patchain.FieldFilter{Field: "timestamp", Op: patchain.FilterGTE, Value: 1}, `"timestamp" >= ?`, []interface{}{1}},
+			{"lt", patchain.FieldFilter{Field: "timestamp", Op: patchain.FilterLT, Value: 1}, `"timestamp" < ?`, []interface{}{1}},
+			{"lte", patchain.FieldFilter{Field: "timestamp", Op: patchain.FilterLTE, Value: 1}, `"timestamp" <= ?`, []interface{}{1}},
+			{"in", patchain.FieldFilter{Field: "key", Op: patchain.FilterIn, Value: []string{"a", "b"}}, `"key" IN (?)`, []interface{}{[]string{"a", "b"}}},
+			{"isnull true", patchain.FieldFilter{Field: "key", Op: patchain.FilterIsNull, Value: true}, `"key" IS NULL`, nil},
+			{"isnull false", patchain.FieldFilter{Field: "key", Op: patchain.FilterIsNull, Value: false}, `"key" IS NOT NULL`, nil},
+			{"between", patchain.FieldFilter{Field: "timestamp", Op: patchain.FilterBetween, Value: []interface{}{1, 2}}, `"timestamp" BETWEEN ? AND ?`, []interface{}{1, 2}},
+			{"between with a concrete slice type", patchain.FieldFilter{Field: "timestamp", Op: patchain.FilterBetween, Value: []int{1, 2}}, `"timestamp" BETWEEN ? AND ?`, []interface{}{1, 2}},
+		}
+
+		for _, c := range cases {
+			Convey(c.desc, func() {
+				expr, args, err := filterSQL(c.filter, crdb)
+				So(err, ShouldBeNil)
+				So(expr, ShouldEqual, c.wantExpr)
+				So(args, ShouldResemble, c.wantArgs)
+			})
+		}
+
+		Convey("unknown operator returns an error", func() {
+			_, _, err := filterSQL(patchain.FieldFilter{Field: "key", Op: "bogus"}, crdb)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("between with a non-slice value returns an error", func() {
+			_, _, err := filterSQL(patchain.FieldFilter{Field: "key", Op: patchain.FilterBetween, Value: 1}, crdb)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("between with a slice of the wrong length returns an error", func() {
+			_, _, err := filterSQL(patchain.FieldFilter{Field: "key", Op: patchain.FilterBetween, Value: []int{1, 2, 3}}, crdb)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("dialects without a native ILIKE fall back to LOWER(col) LIKE LOWER(?)", func() {
+			mysql := driver.MySQLDialect{}
+
+			expr, args, err := filterSQL(patchain.FieldFilter{Field: "key", Op: patchain.FilterIExact, Value: "a"}, mysql)
+			So(err, ShouldBeNil)
+			So(expr, ShouldEqual, "LOWER(`key`) LIKE LOWER(?)")
+			So(args, ShouldResemble, []interface{}{"a"})
+
+			expr, args, err = filterSQL(patchain.FieldFilter{Field: "key", Op: patchain.FilterIContains, Value: "a"}, mysql)
+			So(err, ShouldBeNil)
+			So(expr, ShouldEqual, "LOWER(`key`) LIKE LOWER(?)")
+			So(args, ShouldResemble, []interface{}{"%a%"})
+		})
+	})
+}
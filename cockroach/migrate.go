@@ -0,0 +1,195 @@
+package cockroach
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ellcrys/gorm"
+	"github.com/ellcrys/patchain/cockroach/migrations"
+	"github.com/ellcrys/patchain/cockroach/tables"
+	"github.com/ellcrys/patchain/driver"
+)
+
+// schemaLockVersion is the reserved schema_meta.version used purely to hold
+// a row that migration runs lock with dialect.LockSentinelRow, so that
+// concurrent nodes applying migrations against the same database don't
+// race each other.
+const schemaLockVersion = 0
+
+func init() {
+	migrations.Register(migrations.Migration{
+		Version: 1,
+		Name:    "create_objects_table",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&tables.Object{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTable(&tables.Object{}).Error
+		},
+	})
+}
+
+// CreateTables brings a fresh database up to the latest known schema
+// version. It creates the schema_meta table if it doesn't exist yet (which
+// Migrate requires but does not do itself) and then runs Migrate, and is kept
+// as the entry point existing callers already use.
+func (cdb *DB) CreateTables() error {
+	if err := cdb.db.AutoMigrate(&tables.SchemaMeta{}).Error; err != nil {
+		return err
+	}
+	return cdb.Migrate()
+}
+
+// applyDialectSchema runs any extra DDL dialect requires on top of what
+// gorm's AutoMigrate already applied to the objects and schema_meta tables,
+// inside its own transaction.
+func applyDialectSchema(db *gorm.DB, dialect driver.Dialect) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, model := range []*driver.ObjectModel{{TableName: "objects"}, {TableName: "schema_meta"}} {
+		for _, stmt := range dialect.CreateSchemaSQL(model) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit().Error
+}
+
+// applyMigration runs m.Up and records it in schema_meta inside its own
+// transaction, so that a later migration's failure can't roll it back.
+func applyMigration(db *gorm.DB, m migrations.Migration) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := m.Up(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %d (%s) failed: %s", m.Version, m.Name, err)
+	}
+	if err := tx.Create(&tables.SchemaMeta{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// Migrate applies all pending migrations up to the highest version known to
+// this binary.
+func (cdb *DB) Migrate() error {
+	return cdb.MigrateTo(migrations.MaxVersion())
+}
+
+// MigrateTo applies all pending migrations up to and including target, in
+// order, each inside its own transaction. If a migration fails, only its own
+// transaction is rolled back and the error is returned without applying any
+// later migration; previously applied migrations remain committed.
+//
+// The whole run is serialized against concurrent nodes migrating the same
+// database by a lock held on the schema_meta sentinel row for the duration
+// of the run, in a separate transaction kept open until every migration (and
+// the one-time dialect schema adjustment on a fresh database) has been
+// applied.
+func (cdb *DB) MigrateTo(target int) error {
+
+	lockTx := cdb.db.Begin()
+	if lockTx.Error != nil {
+		return lockTx.Error
+	}
+
+	if err := cdb.lockSchemaMeta(lockTx); err != nil {
+		lockTx.Rollback()
+		return err
+	}
+
+	current, err := schemaVersionOf(lockTx, cdb.getDialect())
+	if err != nil {
+		lockTx.Rollback()
+		return err
+	}
+	bootstrapping := current == 0
+
+	for _, m := range migrations.All() {
+		if m.Version <= current || m.Version > target {
+			continue
+		}
+		if err := applyMigration(cdb.db, m); err != nil {
+			lockTx.Rollback()
+			return err
+		}
+	}
+
+	// applyDialectSchema's DDL (e.g. MySQL's CONVERT TO CHARACTER SET) is a
+	// one-time adjustment on top of what AutoMigrate already applied to a
+	// fresh database; bootstrapping means this is that first run, and
+	// running it before releasing the lock above keeps two nodes
+	// bootstrapping concurrently from racing each other issuing it.
+	if bootstrapping {
+		if err := applyDialectSchema(cdb.db, cdb.getDialect()); err != nil {
+			lockTx.Rollback()
+			return err
+		}
+	}
+
+	return lockTx.Commit().Error
+}
+
+// SchemaVersion returns the highest migration version applied to the
+// database, or 0 if none have been applied yet.
+func (cdb *DB) SchemaVersion() (int, error) {
+	return schemaVersionOf(cdb.db, cdb.getDialect())
+}
+
+// AssertSchemaCompatible returns an error if the database's applied schema
+// version is newer than the highest version known to this binary, which
+// would mean it can't safely operate on the schema. It also returns an
+// error if the schema version can't be determined at all (e.g. the database
+// is unreachable), since starting up unable to tell is exactly when refusing
+// to start matters most. A database without a schema_meta table counts as
+// version 0 and passes.
+func (cdb *DB) AssertSchemaCompatible() error {
+	applied, err := cdb.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if known := migrations.MaxVersion(); applied > known {
+		return fmt.Errorf("database schema is at version %d but this binary only knows migrations up to version %d; refusing to start", applied, known)
+	}
+	return nil
+}
+
+// schemaVersionOf returns the highest schema_meta.version recorded on db,
+// ignoring the reserved lock sentinel row. A database that hasn't been
+// migrated yet (no schema_meta table) is version 0, not an error.
+func schemaVersionOf(db *gorm.DB, dialect driver.Dialect) (int, error) {
+	var meta tables.SchemaMeta
+	err := db.Where("version > ?", schemaLockVersion).Order("version desc").First(&meta).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, nil
+	}
+	if err != nil && dialect.IsUndefinedTableError(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return meta.Version, nil
+}
+
+// lockSchemaMeta ensures the reserved lock sentinel row exists and takes a
+// dialect-appropriate lock on it for the lifetime of tx, so that a
+// concurrent node running MigrateTo blocks until tx commits or rolls back.
+func (cdb *DB) lockSchemaMeta(tx *gorm.DB) error {
+	dialect := cdb.getDialect()
+	if err := tx.Exec(
+		fmt.Sprintf("INSERT INTO schema_meta (version, name, applied_at) VALUES (?, ?, %s) %s",
+			dialect.Now(), dialect.OnConflictUpsert("version")),
+		schemaLockVersion, "lock",
+	).Error; err != nil {
+		return err
+	}
+	return dialect.LockSentinelRow(tx, schemaLockVersion)
+}
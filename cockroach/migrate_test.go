@@ -0,0 +1,136 @@
+package cockroach
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ellcrys/gorm"
+	"github.com/ellcrys/patchain/cockroach/migrations"
+	"github.com/ellcrys/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var migrateDBName = "test_migrate_" + strings.ToLower(util.RandString(5))
+var migrateConStr = "postgresql://root@localhost:26257/" + migrateDBName + "?sslmode=disable"
+
+func TestMigrate(t *testing.T) {
+
+	if _, err := testDB.Query(fmt.Sprintf("CREATE DATABASE %s;", migrateDBName)); err != nil {
+		t.Fatalf("failed to create test database. %s", err)
+	}
+	defer testDB.Query(fmt.Sprintf("DROP DATABASE %s;", migrateDBName))
+
+	cdb := NewDB()
+	cdb.ConnectionString = migrateConStr
+	cdb.NoLogging()
+	if err := cdb.Connect(0, 5); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+
+	Convey("Migrate", t, func() {
+
+		Convey(".CreateTables / .SchemaVersion", func() {
+			Convey("Should apply the baseline migration and record its version", func() {
+				err := cdb.CreateTables()
+				So(err, ShouldBeNil)
+				version, err := cdb.SchemaVersion()
+				So(err, ShouldBeNil)
+				So(version, ShouldEqual, migrations.MaxVersion())
+			})
+		})
+
+		Convey(".MigrateTo", func() {
+			Convey("Should stop and roll back a failing migration, leaving prior versions applied", func() {
+				err := cdb.CreateTables()
+				So(err, ShouldBeNil)
+				baseline, err := cdb.SchemaVersion()
+				So(err, ShouldBeNil)
+
+				_, restore := migrations.Snapshot()
+				Reset(restore)
+
+				migrations.Register(migrations.Migration{
+					Version: baseline + 1,
+					Name:    "boom",
+					Up: func(db *gorm.DB) error {
+						return fmt.Errorf("simulated failure")
+					},
+				})
+
+				err = cdb.MigrateTo(baseline + 1)
+				So(err, ShouldNotBeNil)
+
+				version, err := cdb.SchemaVersion()
+				So(err, ShouldBeNil)
+				So(version, ShouldEqual, baseline)
+			})
+
+			Convey("Should keep earlier migrations in a pending chain committed when a later one fails", func() {
+				err := cdb.CreateTables()
+				So(err, ShouldBeNil)
+				baseline, err := cdb.SchemaVersion()
+				So(err, ShouldBeNil)
+
+				_, restore := migrations.Snapshot()
+				Reset(restore)
+
+				migrations.Register(migrations.Migration{
+					Version: baseline + 1,
+					Name:    "ok_1",
+					Up:      func(db *gorm.DB) error { return nil },
+				})
+				migrations.Register(migrations.Migration{
+					Version: baseline + 2,
+					Name:    "boom",
+					Up:      func(db *gorm.DB) error { return fmt.Errorf("simulated failure") },
+				})
+				migrations.Register(migrations.Migration{
+					Version: baseline + 3,
+					Name:    "never_reached",
+					Up:      func(db *gorm.DB) error { return nil },
+				})
+
+				err = cdb.MigrateTo(baseline + 3)
+				So(err, ShouldNotBeNil)
+
+				version, err := cdb.SchemaVersion()
+				So(err, ShouldBeNil)
+				So(version, ShouldEqual, baseline+1)
+			})
+
+			Convey("Should succeed once the failing migration is fixed and retried", func() {
+				err := cdb.CreateTables()
+				So(err, ShouldBeNil)
+				baseline, err := cdb.SchemaVersion()
+				So(err, ShouldBeNil)
+
+				_, restore := migrations.Snapshot()
+				Reset(restore)
+
+				migrations.Register(migrations.Migration{
+					Version: baseline + 1,
+					Name:    "boom",
+					Up:      func(db *gorm.DB) error { return fmt.Errorf("simulated failure") },
+				})
+				So(cdb.MigrateTo(baseline+1), ShouldNotBeNil)
+
+				restore()
+				migrations.Register(migrations.Migration{
+					Version: baseline + 1,
+					Name:    "fixed",
+					Up: func(db *gorm.DB) error {
+						return nil
+					},
+				})
+
+				err = cdb.MigrateTo(baseline + 1)
+				So(err, ShouldBeNil)
+
+				version, err := cdb.SchemaVersion()
+				So(err, ShouldBeNil)
+				So(version, ShouldEqual, baseline+1)
+			})
+		})
+	})
+}
@@ -0,0 +1,65 @@
+// Package migrations provides a small, in-process registry of schema
+// migrations applied by cockroach.DB. Each backend package (currently just
+// cockroach) registers its own Migration values via init(); the registry
+// itself has no knowledge of the database connection.
+package migrations
+
+import "github.com/ellcrys/gorm"
+
+// Migration describes a single, versioned schema change.
+type Migration struct {
+	// Version is the migration's position in the sequence. Versions must be
+	// unique and are applied in ascending order.
+	Version int
+
+	// Name is a short, human-readable identifier recorded alongside the
+	// version in schema_meta once the migration has been applied.
+	Name string
+
+	// Up applies the migration.
+	Up func(*gorm.DB) error
+
+	// Down reverses the migration.
+	Down func(*gorm.DB) error
+}
+
+var registry []Migration
+
+// Register adds m to the set of known migrations. It is meant to be called
+// from package init() functions.
+func Register(m Migration) {
+	registry = append(registry, m)
+}
+
+// All returns the registered migrations sorted by ascending version.
+func All() []Migration {
+	sorted := make([]Migration, len(registry))
+	copy(sorted, registry)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j-1].Version > sorted[j].Version; j-- {
+			sorted[j-1], sorted[j] = sorted[j], sorted[j-1]
+		}
+	}
+	return sorted
+}
+
+// MaxVersion returns the highest version among the registered migrations, or
+// 0 if none are registered.
+func MaxVersion() int {
+	max := 0
+	for _, m := range registry {
+		if m.Version > max {
+			max = m.Version
+		}
+	}
+	return max
+}
+
+// Snapshot returns the current registry contents and a restore function that
+// resets the registry back to them. It exists so tests can register
+// temporary, scenario-specific migrations (e.g. one that deliberately
+// fails) without permanently affecting the rest of the test suite.
+func Snapshot() (saved []Migration, restore func()) {
+	saved = append([]Migration{}, registry...)
+	return saved, func() { registry = saved }
+}
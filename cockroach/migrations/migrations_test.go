@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/ellcrys/gorm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMigrations(t *testing.T) {
+	Convey("Migrations", t, func() {
+
+		saved, restore := Snapshot()
+		registry = nil
+		Reset(restore)
+		_ = saved
+
+		noop := func(*gorm.DB) error { return nil }
+
+		Convey(".Register and .All", func() {
+			Convey("Should return migrations ordered by ascending version", func() {
+				Register(Migration{Version: 3, Name: "c", Up: noop})
+				Register(Migration{Version: 1, Name: "a", Up: noop})
+				Register(Migration{Version: 2, Name: "b", Up: noop})
+
+				all := All()
+				So(len(all), ShouldEqual, 3)
+				So(all[0].Version, ShouldEqual, 1)
+				So(all[1].Version, ShouldEqual, 2)
+				So(all[2].Version, ShouldEqual, 3)
+			})
+		})
+
+		Convey(".MaxVersion", func() {
+			Convey("Should return 0 when nothing is registered", func() {
+				So(MaxVersion(), ShouldEqual, 0)
+			})
+
+			Convey("Should return the highest registered version", func() {
+				Register(Migration{Version: 5, Name: "e", Up: noop})
+				Register(Migration{Version: 2, Name: "b", Up: noop})
+				So(MaxVersion(), ShouldEqual, 5)
+			})
+		})
+
+		Convey(".Snapshot", func() {
+			Convey("Should restore the registry to its prior contents", func() {
+				Register(Migration{Version: 1, Name: "a", Up: noop})
+				snap, restoreInner := Snapshot()
+				Register(Migration{Version: 2, Name: "temporary", Up: noop})
+				So(MaxVersion(), ShouldEqual, 2)
+				restoreInner()
+				So(MaxVersion(), ShouldEqual, 1)
+				So(len(snap), ShouldEqual, 1)
+			})
+		})
+	})
+}
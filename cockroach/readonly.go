@@ -0,0 +1,46 @@
+package cockroach
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ellcrys/patchain"
+)
+
+// BeginReadOnly starts a read-only transaction suitable for running several
+// consistent reads (e.g. GetLast, Count, GetAll) without blocking or being
+// blocked by concurrent writers. Passing a ReadOnlyOption with a positive
+// AsOfSystemTime asks cdb.dialect for a bounded-staleness read that avoids
+// contending with concurrent writers at all, on dialects that support it
+// (e.g. CockroachDB's follower reads); dialects that don't just return a
+// plain read-only transaction.
+//
+// If the backend rejects any of the statements that establish this
+// guarantee, the transaction is rolled back and the error is returned; the
+// caller must not treat a non-nil handle as read-only unless err is nil.
+//
+// Create, CreateBulk and UpdatePeerHash return patchain.ErrReadOnly when
+// called against the returned handle.
+func (cdb *DB) BeginReadOnly(opts ...*patchain.ReadOnlyOption) (patchain.DB, error) {
+
+	var asOf time.Duration
+	for _, opt := range opts {
+		if opt != nil && opt.AsOfSystemTime > asOf {
+			asOf = opt.AsOfSystemTime
+		}
+	}
+
+	tx := cdb.db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	for _, stmt := range cdb.getDialect().ReadOnlyStatements(asOf) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to establish read-only transaction: %s", err)
+		}
+	}
+
+	return &DB{ConnectionString: cdb.ConnectionString, logging: cdb.logging, dialect: cdb.getDialect(), db: tx, readOnly: true}, nil
+}
@@ -0,0 +1,124 @@
+package cockroach
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ellcrys/patchain"
+	"github.com/ellcrys/patchain/cockroach/tables"
+	"github.com/ellcrys/util"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var readOnlyDBName = "test_readonly_" + strings.ToLower(util.RandString(5))
+var readOnlyConStr = "postgresql://root@localhost:26257/" + readOnlyDBName + "?sslmode=disable"
+
+func TestReadOnly(t *testing.T) {
+
+	if _, err := testDB.Query(fmt.Sprintf("CREATE DATABASE %s;", readOnlyDBName)); err != nil {
+		t.Fatalf("failed to create test database. %s", err)
+	}
+	defer testDB.Query(fmt.Sprintf("DROP DATABASE %s;", readOnlyDBName))
+
+	cdb := NewDB()
+	cdb.ConnectionString = readOnlyConStr
+	cdb.NoLogging()
+	if err := cdb.Connect(0, 5); err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	if err := cdb.CreateTables(); err != nil {
+		t.Fatalf("failed to create tables: %s", err)
+	}
+
+	// writer is a second, independently connected handle used to commit
+	// writes concurrently with a snapshot read held open on cdb, so those
+	// writes land in a transaction genuinely distinct from the reader's.
+	writer := NewDB()
+	writer.ConnectionString = readOnlyConStr
+	writer.NoLogging()
+	if err := writer.Connect(0, 5); err != nil {
+		t.Fatalf("failed to connect writer: %s", err)
+	}
+
+	Convey(".BeginReadOnly", t, func() {
+
+		Convey("Should allow reads", func() {
+			roTx, err := cdb.BeginReadOnly()
+			So(err, ShouldBeNil)
+			var dst []tables.Object
+			err = roTx.GetAll(&tables.Object{}, &dst)
+			So(err, ShouldBeNil)
+			So(roTx.Rollback(), ShouldBeNil)
+		})
+
+		Convey("Should reject Create with ErrReadOnly", func() {
+			roTx, err := cdb.BeginReadOnly()
+			So(err, ShouldBeNil)
+			err = roTx.Create(&tables.Object{})
+			So(err, ShouldEqual, patchain.ErrReadOnly)
+			So(roTx.Rollback(), ShouldBeNil)
+		})
+
+		Convey("Should reject CreateBulk with ErrReadOnly", func() {
+			roTx, err := cdb.BeginReadOnly()
+			So(err, ShouldBeNil)
+			err = roTx.CreateBulk([]interface{}{&tables.Object{}})
+			So(err, ShouldEqual, patchain.ErrReadOnly)
+			So(roTx.Rollback(), ShouldBeNil)
+		})
+
+		Convey("Should reject UpdatePeerHash with ErrReadOnly", func() {
+			roTx, err := cdb.BeginReadOnly()
+			So(err, ShouldBeNil)
+			err = roTx.UpdatePeerHash(&tables.Object{}, "some_hash")
+			So(err, ShouldEqual, patchain.ErrReadOnly)
+			So(roTx.Rollback(), ShouldBeNil)
+		})
+
+		Convey("Should not affect writes on the originating handle", func() {
+			roTx, err := cdb.BeginReadOnly()
+			So(err, ShouldBeNil)
+			So(roTx.Rollback(), ShouldBeNil)
+			err = cdb.Create(&tables.Object{})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Should not see writes committed by another transaction after the snapshot was taken", func() {
+			roTx, err := cdb.BeginReadOnly()
+			So(err, ShouldBeNil)
+
+			var before []tables.Object
+			So(roTx.GetAll(&tables.Object{}, &before), ShouldBeNil)
+
+			So(writer.Create((&tables.Object{Key: "concurrent-write"}).Init().ComputeHash()), ShouldBeNil)
+
+			var after []tables.Object
+			So(roTx.GetAll(&tables.Object{}, &after), ShouldBeNil)
+			So(len(after), ShouldEqual, len(before))
+
+			So(roTx.Rollback(), ShouldBeNil)
+
+			var postRollback []tables.Object
+			So(cdb.GetAll(&tables.Object{}, &postRollback), ShouldBeNil)
+			So(len(postRollback), ShouldEqual, len(before)+1)
+		})
+
+		Convey("AS OF SYSTEM TIME should not see a write committed after the read timestamp", func() {
+			roTx, err := cdb.BeginReadOnly(&patchain.ReadOnlyOption{AsOfSystemTime: 200 * time.Millisecond})
+			So(err, ShouldBeNil)
+
+			var before []tables.Object
+			So(roTx.GetAll(&tables.Object{}, &before), ShouldBeNil)
+
+			So(writer.Create((&tables.Object{Key: "concurrent-write-asof"}).Init().ComputeHash()), ShouldBeNil)
+
+			var after []tables.Object
+			So(roTx.GetAll(&tables.Object{}, &after), ShouldBeNil)
+			So(len(after), ShouldEqual, len(before))
+
+			So(roTx.Rollback(), ShouldBeNil)
+		})
+	})
+}
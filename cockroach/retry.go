@@ -0,0 +1,81 @@
+package cockroach
+
+import (
+	"time"
+
+	"github.com/ellcrys/patchain"
+	"github.com/ellcrys/patchain/driver"
+)
+
+// defaultMaxRetryAttempts is the attempt cap RunInNewTxn always uses, and
+// the one TransactWithDB uses when its patchain.RetryOnConflict option has
+// no positive MaxAttempts.
+const defaultMaxRetryAttempts = 10
+
+// initialRetryBackoff is the base delay used by the exponential backoff
+// between retry attempts.
+const initialRetryBackoff = 10 * time.Millisecond
+
+// IsRetryable reports whether err represents a CockroachDB serialization
+// conflict that can be resolved by retrying the transaction from scratch.
+// It is kept for existing callers that classify errors without a DB handle
+// in hand; DB methods instead consult their own configured dialect, via
+// dialect.IsRetryable, so that the classification matches the backend
+// actually in use.
+func IsRetryable(err error) bool {
+	return driver.CockroachDialect{}.IsRetryable(err)
+}
+
+// RunInNewTxn opens a fresh transaction and runs fn against it, committing on
+// success. When retryable is true and the error returned by fn (or by the
+// commit itself) is classified as retryable by cdb's dialect (see
+// driver.Dialect.IsRetryable), the transaction is rolled back and the whole
+// attempt is retried with an exponential backoff, up to
+// defaultMaxRetryAttempts times.
+func (cdb *DB) RunInNewTxn(retryable bool, fn func(db patchain.DB) error) error {
+	maxAttempts := defaultMaxRetryAttempts
+	backoff := initialRetryBackoff
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		dbTx := cdb.Begin()
+
+		if err := fn(dbTx); err != nil {
+			dbTx.Rollback()
+			if retryable && cdb.getDialect().IsRetryable(err) {
+				lastErr = err
+				continue
+			}
+			return err
+		}
+
+		if err := dbTx.Commit(); err != nil {
+			if retryable && cdb.getDialect().IsRetryable(err) {
+				lastErr = err
+				continue
+			}
+			return err
+		}
+
+		return nil
+	}
+
+	return lastErr
+}
+
+// retryAttemptsOf returns the configured max attempts carried by a
+// patchain.RetryOnConflict option in opts and true, or 0 and false if none
+// is present.
+func retryAttemptsOf(opts []patchain.Option) (int, bool) {
+	for _, opt := range opts {
+		if retry, ok := opt.(*patchain.RetryOnConflict); ok {
+			return retry.MaxAttempts, true
+		}
+	}
+	return 0, false
+}
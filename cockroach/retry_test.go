@@ -0,0 +1,37 @@
+package cockroach
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIsRetryable(t *testing.T) {
+	Convey("IsRetryable", t, func() {
+
+		Convey("Should return false for a nil error", func() {
+			So(IsRetryable(nil), ShouldEqual, false)
+		})
+
+		Convey("Should return true for a pq.Error with code 40001", func() {
+			err := &pq.Error{Code: "40001"}
+			So(IsRetryable(err), ShouldEqual, true)
+		})
+
+		Convey("Should return false for a pq.Error with a different code", func() {
+			err := &pq.Error{Code: "23505"}
+			So(IsRetryable(err), ShouldEqual, false)
+		})
+
+		Convey("Should return true for a CRDB restart transaction message", func() {
+			err := errors.New("restart transaction: retry txn (RETRY_SERIALIZABLE - failed preemptive refresh): CockroachDB restart transaction")
+			So(IsRetryable(err), ShouldEqual, true)
+		})
+
+		Convey("Should return false for an unrelated error", func() {
+			So(IsRetryable(errors.New("connection refused")), ShouldEqual, false)
+		})
+	})
+}
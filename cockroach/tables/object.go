@@ -0,0 +1,62 @@
+// Package tables contains the gorm model definitions persisted by the
+// cockroach backend.
+package tables
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ellcrys/patchain"
+	"github.com/ellcrys/util"
+)
+
+// Object is the single, generic chained record type used throughout
+// patchain. Every piece of application data is stored as a Key/Value pair
+// chained to the previous object via PrevHash/PeerHash.
+type Object struct {
+	patchain.QueryParams `sql:"-"`
+
+	ID          string `gorm:"primary_key"`
+	CreatorID   string
+	OwnerID     string
+	PartitionID string
+	Key         string
+	Value       string
+	Hash        string
+	PrevHash    string
+	PeerHash    string
+	Timestamp   int64
+}
+
+// Init assigns an ID and Timestamp to the object if they are not already
+// set. It is safe to call multiple times; subsequent calls are no-ops.
+func (o *Object) Init() *Object {
+	if o.ID == "" {
+		o.ID = util.UUID4()
+	}
+	if o.Timestamp == 0 {
+		o.Timestamp = time.Now().UnixNano()
+	}
+	return o
+}
+
+// ComputeHash derives and sets o.Hash from the object's identity and content
+// fields. Calling it again without changing the object yields the same
+// hash.
+func (o *Object) ComputeHash() *Object {
+	o.Hash = util.Sha256(fmt.Sprintf("%s/%s/%s/%s/%s/%s/%d", o.ID, o.CreatorID, o.OwnerID, o.PartitionID, o.Key, o.Value, o.Timestamp))
+	return o
+}
+
+// ComputePeerHash sets o.PeerHash to the hash linking o to the next object
+// in the chain, identified by nextObjHash.
+func (o *Object) ComputePeerHash(nextObjHash string) *Object {
+	o.PeerHash = util.Sha256(fmt.Sprintf("%s/%s", o.Hash, nextObjHash))
+	return o
+}
+
+// GetQueryParams returns the query params embedded in o, satisfying
+// patchain.QueryParamsGetter.
+func (o *Object) GetQueryParams() *patchain.QueryParams {
+	return &o.QueryParams
+}
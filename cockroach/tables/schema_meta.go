@@ -0,0 +1,18 @@
+package tables
+
+import "time"
+
+// SchemaMeta records a single applied migration. The row with Version 0 is
+// reserved as a lock sentinel used to serialize concurrent migration runs
+// across nodes; it is never a real migration.
+type SchemaMeta struct {
+	Version   int `gorm:"primary_key"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName overrides gorm's default pluralization so the table is named
+// schema_meta rather than schema_metas.
+func (SchemaMeta) TableName() string {
+	return "schema_meta"
+}
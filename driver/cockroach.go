@@ -0,0 +1,106 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ellcrys/gorm"
+	"github.com/lib/pq"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// crdbSQLStateSerializationFailure is the SQLSTATE CockroachDB (and
+// Postgres) return when a transaction can't be committed because of a
+// serializable conflict with a concurrent transaction.
+const crdbSQLStateSerializationFailure = "40001"
+
+// crdbRestartTxnMessage is a substring CockroachDB includes in the error
+// message of restartable transactions that are not always surfaced as a
+// *pq.Error with the 40001 code (e.g. when wrapped).
+const crdbRestartTxnMessage = "CockroachDB restart transaction"
+
+// crdbUndefinedTable is the SQLSTATE Postgres/CockroachDB return when a
+// query references a table that doesn't exist yet.
+const crdbUndefinedTable = "42P01"
+
+// CockroachDialect implements Dialect for CockroachDB. It remains the
+// default dialect used by cockroach.NewDB for backward compatibility.
+type CockroachDialect struct{}
+
+// Name implements Dialect.
+func (CockroachDialect) Name() string { return "cockroach" }
+
+// Open implements Dialect.
+func (CockroachDialect) Open(connStr string) (*gorm.DB, error) {
+	return gorm.Open("postgres", connStr)
+}
+
+// QuoteIdent implements Dialect.
+func (CockroachDialect) QuoteIdent(s string) string {
+	return fmt.Sprintf(`"%s"`, s)
+}
+
+// SupportsILike implements Dialect.
+func (CockroachDialect) SupportsILike() bool { return true }
+
+// TruncateSQL implements Dialect.
+func (d CockroachDialect) TruncateSQL(table string) string {
+	return fmt.Sprintf("TRUNCATE %s", table)
+}
+
+// Now implements Dialect.
+func (CockroachDialect) Now() string { return "now()" }
+
+// OnConflictUpsert implements Dialect.
+func (CockroachDialect) OnConflictUpsert(conflictCol string) string {
+	return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", conflictCol)
+}
+
+// CreateSchemaSQL implements Dialect. CockroachDB needs no adjustment
+// beyond what gorm's AutoMigrate already applies.
+func (CockroachDialect) CreateSchemaSQL(model *ObjectModel) []string { return nil }
+
+// LockSentinelRow implements Dialect.
+func (CockroachDialect) LockSentinelRow(tx *gorm.DB, version int) error {
+	return tx.Exec("SELECT version FROM schema_meta WHERE version = ? FOR UPDATE", version).Error
+}
+
+// BulkInsert implements Dialect.
+func (CockroachDialect) BulkInsert(db *gorm.DB, rows []interface{}) error {
+	return loopInsert(db, rows)
+}
+
+// IsRetryable implements Dialect. It reports whether err represents a
+// CockroachDB serialization conflict that can be resolved by retrying the
+// transaction from scratch.
+func (CockroachDialect) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == crdbSQLStateSerializationFailure {
+		return true
+	}
+	return strings.Contains(err.Error(), crdbRestartTxnMessage)
+}
+
+// IsUndefinedTableError implements Dialect.
+func (CockroachDialect) IsUndefinedTableError(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && string(pqErr.Code) == crdbUndefinedTable
+}
+
+// ReadOnlyStatements implements Dialect. A positive asOfSystemTime reads as
+// of a bounded time in the past via a CockroachDB follower read, which
+// doesn't contend with the leaseholder at all.
+func (CockroachDialect) ReadOnlyStatements(asOfSystemTime time.Duration) []string {
+	stmts := []string{"SET TRANSACTION READ ONLY"}
+	if asOfSystemTime > 0 {
+		stmts = append(stmts, fmt.Sprintf(
+			"SET TRANSACTION AS OF SYSTEM TIME follower_read_timestamp() - interval '%f seconds'",
+			asOfSystemTime.Seconds(),
+		))
+	}
+	return stmts
+}
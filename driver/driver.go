@@ -0,0 +1,105 @@
+// Package driver abstracts the SQL-dialect differences between the database
+// backends patchain can run on, so that cockroach.DB doesn't hard-code a
+// CockroachDB connection string or CockroachDB/Postgres-specific SQL.
+//
+// CockroachDialect is exercised against a live database by this repo's
+// default test suite (cockroach_test.go and friends). PostgresDialect,
+// MySQLDialect and SQLiteDialect are covered by the pure-function tests in
+// driver_test.go, plus the cockroach.DB wiring suite in
+// cockroach/dialect_integration_test.go, which runs against live servers
+// but is gated behind the dialect_integration build tag since most
+// environments don't have all four backends available at once.
+package driver
+
+import (
+	"time"
+
+	"github.com/ellcrys/gorm"
+)
+
+// ObjectModel describes a table whose schema a Dialect may need to refine
+// beyond what gorm's AutoMigrate already expresses (e.g. an explicit
+// character set).
+type ObjectModel struct {
+	// TableName is the table's name as gorm derived it (e.g. "objects").
+	TableName string
+}
+
+// Dialect abstracts the SQL/behavioural differences between the backends
+// patchain can run on.
+type Dialect interface {
+
+	// Name identifies the dialect, e.g. for logging.
+	Name() string
+
+	// Open connects to connStr using this dialect's gorm driver.
+	Open(connStr string) (*gorm.DB, error)
+
+	// QuoteIdent quotes a bare column/table identifier the way this
+	// dialect's SQL parser expects.
+	QuoteIdent(s string) string
+
+	// SupportsILike reports whether the dialect has a native
+	// case-insensitive LIKE operator (ILIKE). Dialects that don't must
+	// fall back to LOWER(col) LIKE LOWER(?).
+	SupportsILike() bool
+
+	// TruncateSQL returns the statement used to empty table, since not
+	// every dialect supports TRUNCATE.
+	TruncateSQL(table string) string
+
+	// Now returns the SQL expression for the current timestamp.
+	Now() string
+
+	// OnConflictUpsert returns the clause appended to an INSERT so that a
+	// conflict on conflictCol is a no-op, in this dialect's syntax.
+	OnConflictUpsert(conflictCol string) string
+
+	// CreateSchemaSQL returns any extra DDL statements, beyond what gorm's
+	// AutoMigrate already issued, that model requires under this dialect
+	// (e.g. MySQL's explicit utf8mb4 table character set). It returns nil
+	// when AutoMigrate's output needs no further adjustment.
+	CreateSchemaSQL(model *ObjectModel) []string
+
+	// LockSentinelRow takes a lock on the schema_meta row identified by
+	// version for the lifetime of tx, so that a concurrent node applying
+	// migrations against the same database blocks until tx commits or rolls
+	// back. Dialects with nothing like SELECT ... FOR UPDATE (e.g. SQLite,
+	// which never has more than one writer at a time) may no-op.
+	LockSentinelRow(tx *gorm.DB, version int) error
+
+	// BulkInsert persists rows in as few round-trips as the dialect
+	// reasonably allows.
+	BulkInsert(db *gorm.DB, rows []interface{}) error
+
+	// IsRetryable reports whether err represents a conflict that can be
+	// resolved by retrying the transaction from scratch.
+	IsRetryable(err error) bool
+
+	// IsUndefinedTableError reports whether err is the backend's "table
+	// does not exist" error, so callers querying a table that may not have
+	// been created yet (e.g. schema_meta before the first migration) can
+	// treat it as "not found" instead of a hard failure.
+	IsUndefinedTableError(err error) bool
+
+	// ReadOnlyStatements returns the statements BeginReadOnly should Exec
+	// on a fresh transaction to make it read-only and, where the dialect
+	// supports it, to bound it to asOfSystemTime staleness for a read that
+	// doesn't contend with concurrent writers (e.g. CockroachDB's follower
+	// reads). asOfSystemTime of 0 asks for a read as of now. Dialects that
+	// can't honor staleness just return their plain read-only statement.
+	ReadOnlyStatements(asOfSystemTime time.Duration) []string
+}
+
+// loopInsert is the shared BulkInsert fallback: one INSERT per row. It is
+// what every Dialect implemented in this package currently uses; a backend
+// able to batch rows into a single statement can override BulkInsert
+// without any change to cockroach.DB.
+func loopInsert(db *gorm.DB, rows []interface{}) error {
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDialects(t *testing.T) {
+	Convey("Dialect implementations", t, func() {
+
+		dialects := []Dialect{CockroachDialect{}, PostgresDialect{}, MySQLDialect{}, SQLiteDialect{}}
+
+		Convey("Name returns a non-empty, distinct identifier", func() {
+			seen := map[string]bool{}
+			for _, d := range dialects {
+				So(d.Name(), ShouldNotBeEmpty)
+				So(seen[d.Name()], ShouldBeFalse)
+				seen[d.Name()] = true
+			}
+		})
+
+		Convey("QuoteIdent", func() {
+			So(CockroachDialect{}.QuoteIdent("key"), ShouldEqual, `"key"`)
+			So(PostgresDialect{}.QuoteIdent("key"), ShouldEqual, `"key"`)
+			So(MySQLDialect{}.QuoteIdent("key"), ShouldEqual, "`key`")
+			So(SQLiteDialect{}.QuoteIdent("key"), ShouldEqual, `"key"`)
+		})
+
+		Convey("SupportsILike", func() {
+			So(CockroachDialect{}.SupportsILike(), ShouldBeTrue)
+			So(PostgresDialect{}.SupportsILike(), ShouldBeTrue)
+			So(MySQLDialect{}.SupportsILike(), ShouldBeFalse)
+			So(SQLiteDialect{}.SupportsILike(), ShouldBeFalse)
+		})
+
+		Convey("TruncateSQL", func() {
+			So(CockroachDialect{}.TruncateSQL("objects"), ShouldEqual, "TRUNCATE objects")
+			So(PostgresDialect{}.TruncateSQL("objects"), ShouldEqual, "TRUNCATE objects")
+			So(MySQLDialect{}.TruncateSQL("objects"), ShouldEqual, "TRUNCATE TABLE objects")
+			So(SQLiteDialect{}.TruncateSQL("objects"), ShouldEqual, "DELETE FROM objects")
+		})
+
+		Convey("OnConflictUpsert", func() {
+			So(CockroachDialect{}.OnConflictUpsert("version"), ShouldEqual, "ON CONFLICT (version) DO NOTHING")
+			So(PostgresDialect{}.OnConflictUpsert("version"), ShouldEqual, "ON CONFLICT (version) DO NOTHING")
+			So(SQLiteDialect{}.OnConflictUpsert("version"), ShouldEqual, "ON CONFLICT (version) DO NOTHING")
+			So(MySQLDialect{}.OnConflictUpsert("version"), ShouldEqual, "ON DUPLICATE KEY UPDATE version = version")
+		})
+
+		Convey("CreateSchemaSQL", func() {
+			Convey("CockroachDialect, PostgresDialect and SQLiteDialect need no adjustment", func() {
+				So(CockroachDialect{}.CreateSchemaSQL(&ObjectModel{TableName: "objects"}), ShouldBeNil)
+				So(PostgresDialect{}.CreateSchemaSQL(&ObjectModel{TableName: "objects"}), ShouldBeNil)
+				So(SQLiteDialect{}.CreateSchemaSQL(&ObjectModel{TableName: "objects"}), ShouldBeNil)
+			})
+
+			Convey("MySQLDialect converts the table to utf8mb4", func() {
+				stmts := MySQLDialect{}.CreateSchemaSQL(&ObjectModel{TableName: "objects"})
+				So(stmts, ShouldHaveLength, 1)
+				So(stmts[0], ShouldContainSubstring, "utf8mb4")
+				So(stmts[0], ShouldContainSubstring, "objects")
+			})
+		})
+
+		Convey("ReadOnlyStatements", func() {
+			Convey("CockroachDialect adds a follower-read AS OF clause when asOfSystemTime is positive", func() {
+				So(CockroachDialect{}.ReadOnlyStatements(0), ShouldResemble, []string{"SET TRANSACTION READ ONLY"})
+				stmts := CockroachDialect{}.ReadOnlyStatements(5 * time.Second)
+				So(stmts, ShouldHaveLength, 2)
+				So(stmts[1], ShouldContainSubstring, "follower_read_timestamp")
+				So(stmts[1], ShouldContainSubstring, "5.000000")
+			})
+
+			Convey("PostgresDialect and MySQLDialect ignore asOfSystemTime", func() {
+				So(PostgresDialect{}.ReadOnlyStatements(5*time.Second), ShouldResemble, []string{"SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY"})
+				So(MySQLDialect{}.ReadOnlyStatements(5*time.Second), ShouldResemble, []string{"SET TRANSACTION READ ONLY"})
+			})
+
+			Convey("SQLiteDialect needs no statements; write-blocking is app-level", func() {
+				So(SQLiteDialect{}.ReadOnlyStatements(5*time.Second), ShouldBeNil)
+			})
+		})
+
+		Convey("withCharsetDefaults", func() {
+			Convey("Should add charset and parseTime when absent", func() {
+				So(withCharsetDefaults("root@tcp(127.0.0.1:3306)/patchain"), ShouldEqual,
+					"root@tcp(127.0.0.1:3306)/patchain?charset=utf8mb4&parseTime=true")
+			})
+
+			Convey("Should not override a caller-supplied charset", func() {
+				So(withCharsetDefaults("root@tcp(127.0.0.1:3306)/patchain?charset=latin1"), ShouldEqual,
+					"root@tcp(127.0.0.1:3306)/patchain?charset=latin1&parseTime=true")
+			})
+		})
+
+		Convey("IsRetryable", func() {
+
+			Convey("CockroachDialect", func() {
+				d := CockroachDialect{}
+				So(d.IsRetryable(nil), ShouldBeFalse)
+				So(d.IsRetryable(&pq.Error{Code: "40001"}), ShouldBeTrue)
+				So(d.IsRetryable(&pq.Error{Code: "23505"}), ShouldBeFalse)
+				So(d.IsRetryable(errors.New("restart txn: CockroachDB restart transaction")), ShouldBeTrue)
+				So(d.IsRetryable(errors.New("connection refused")), ShouldBeFalse)
+			})
+
+			Convey("PostgresDialect", func() {
+				d := PostgresDialect{}
+				So(d.IsRetryable(nil), ShouldBeFalse)
+				So(d.IsRetryable(&pq.Error{Code: "40001"}), ShouldBeTrue)
+				So(d.IsRetryable(&pq.Error{Code: "23505"}), ShouldBeFalse)
+				So(d.IsRetryable(errors.New("connection refused")), ShouldBeFalse)
+			})
+
+			Convey("SQLiteDialect", func() {
+				d := SQLiteDialect{}
+				So(d.IsRetryable(nil), ShouldBeFalse)
+				So(d.IsRetryable(errors.New("database is locked")), ShouldBeTrue)
+				So(d.IsRetryable(errors.New("no such table: objects")), ShouldBeFalse)
+			})
+
+			Convey("MySQLDialect", func() {
+				d := MySQLDialect{}
+				So(d.IsRetryable(nil), ShouldBeFalse)
+				So(d.IsRetryable(&mysqldriver.MySQLError{Number: 1213}), ShouldBeTrue)
+				So(d.IsRetryable(&mysqldriver.MySQLError{Number: 1205}), ShouldBeTrue)
+				So(d.IsRetryable(&mysqldriver.MySQLError{Number: 1062}), ShouldBeFalse)
+			})
+		})
+
+		Convey("IsUndefinedTableError", func() {
+
+			Convey("CockroachDialect", func() {
+				d := CockroachDialect{}
+				So(d.IsUndefinedTableError(nil), ShouldBeFalse)
+				So(d.IsUndefinedTableError(&pq.Error{Code: "42P01"}), ShouldBeTrue)
+				So(d.IsUndefinedTableError(&pq.Error{Code: "23505"}), ShouldBeFalse)
+			})
+
+			Convey("PostgresDialect", func() {
+				d := PostgresDialect{}
+				So(d.IsUndefinedTableError(nil), ShouldBeFalse)
+				So(d.IsUndefinedTableError(&pq.Error{Code: "42P01"}), ShouldBeTrue)
+				So(d.IsUndefinedTableError(&pq.Error{Code: "23505"}), ShouldBeFalse)
+			})
+
+			Convey("MySQLDialect", func() {
+				d := MySQLDialect{}
+				So(d.IsUndefinedTableError(nil), ShouldBeFalse)
+				So(d.IsUndefinedTableError(&mysqldriver.MySQLError{Number: 1146}), ShouldBeTrue)
+				So(d.IsUndefinedTableError(&mysqldriver.MySQLError{Number: 1062}), ShouldBeFalse)
+			})
+
+			Convey("SQLiteDialect", func() {
+				d := SQLiteDialect{}
+				So(d.IsUndefinedTableError(nil), ShouldBeFalse)
+				So(d.IsUndefinedTableError(errors.New("no such table: schema_meta")), ShouldBeTrue)
+				So(d.IsUndefinedTableError(errors.New("database is locked")), ShouldBeFalse)
+			})
+		})
+
+		Convey("LockSentinelRow", func() {
+			Convey("SQLiteDialect no-ops instead of using unsupported FOR UPDATE syntax", func() {
+				So(SQLiteDialect{}.LockSentinelRow(nil, schemaLockVersionForTest), ShouldBeNil)
+			})
+		})
+	})
+}
+
+// schemaLockVersionForTest mirrors cockroach.schemaLockVersion; this package
+// can't import cockroach (it would be a cyclic import), so the reserved
+// sentinel version is just a plain 0 here.
+const schemaLockVersionForTest = 0
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ellcrys/gorm"
+	mysqldriver "github.com/go-sql-driver/mysql"
+
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+)
+
+// mysqlDeadlockErrNo and mysqlLockWaitTimeoutErrNo are the MySQL error
+// numbers returned for a detected deadlock or a lock-wait timeout,
+// respectively. Both are resolved the same way a CockroachDB serialization
+// conflict is: roll back and retry.
+const (
+	mysqlDeadlockErrNo        = 1213
+	mysqlLockWaitTimeoutErrNo = 1205
+)
+
+// mysqlUndefinedTableErrNo is the MySQL error number returned when a query
+// references a table that doesn't exist yet.
+const mysqlUndefinedTableErrNo = 1146
+
+// MySQLDialect implements Dialect for MySQL/MariaDB.
+type MySQLDialect struct{}
+
+// Name implements Dialect.
+func (MySQLDialect) Name() string { return "mysql" }
+
+// Open implements Dialect. It ensures the connection uses utf8mb4 (MySQL's
+// plain "utf8" charset can't represent the full Unicode range patchain's
+// Value column may hold) and that DATETIME/TIMESTAMP columns scan into
+// time.Time, adding both to connStr if the caller didn't already set them.
+func (MySQLDialect) Open(connStr string) (*gorm.DB, error) {
+	return gorm.Open("mysql", withCharsetDefaults(connStr))
+}
+
+// withCharsetDefaults appends charset=utf8mb4 and parseTime=true to connStr
+// if the caller didn't already set them.
+func withCharsetDefaults(connStr string) string {
+	if !strings.Contains(connStr, "charset=") {
+		connStr += sep(connStr) + "charset=utf8mb4"
+	}
+	if !strings.Contains(connStr, "parseTime=") {
+		connStr += sep(connStr) + "parseTime=true"
+	}
+	return connStr
+}
+
+func sep(connStr string) string {
+	if strings.Contains(connStr, "?") {
+		return "&"
+	}
+	return "?"
+}
+
+// QuoteIdent implements Dialect.
+func (MySQLDialect) QuoteIdent(s string) string {
+	return fmt.Sprintf("`%s`", s)
+}
+
+// SupportsILike implements Dialect. MySQL has no ILIKE operator; callers
+// must fall back to LOWER(col) LIKE LOWER(?).
+func (MySQLDialect) SupportsILike() bool { return false }
+
+// TruncateSQL implements Dialect.
+func (MySQLDialect) TruncateSQL(table string) string {
+	return fmt.Sprintf("TRUNCATE TABLE %s", table)
+}
+
+// Now implements Dialect.
+func (MySQLDialect) Now() string { return "NOW()" }
+
+// OnConflictUpsert implements Dialect. MySQL has no ON CONFLICT clause;
+// the equivalent no-op upsert is an UPDATE that writes the conflicting
+// column back to itself.
+func (MySQLDialect) OnConflictUpsert(conflictCol string) string {
+	return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", conflictCol, conflictCol)
+}
+
+// CreateSchemaSQL implements Dialect, converting model's table to utf8mb4
+// since gorm's AutoMigrate does not set a table's character set.
+func (MySQLDialect) CreateSchemaSQL(model *ObjectModel) []string {
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci", model.TableName),
+	}
+}
+
+// LockSentinelRow implements Dialect. MySQL supports the same
+// SELECT ... FOR UPDATE syntax Postgres/CockroachDB use.
+func (MySQLDialect) LockSentinelRow(tx *gorm.DB, version int) error {
+	return tx.Exec("SELECT version FROM schema_meta WHERE version = ? FOR UPDATE", version).Error
+}
+
+// BulkInsert implements Dialect.
+func (MySQLDialect) BulkInsert(db *gorm.DB, rows []interface{}) error {
+	return loopInsert(db, rows)
+}
+
+// IsRetryable implements Dialect. It reports whether err is a MySQL
+// deadlock or lock-wait-timeout error.
+func (MySQLDialect) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	mysqlErr, ok := err.(*mysqldriver.MySQLError)
+	return ok && (mysqlErr.Number == mysqlDeadlockErrNo || mysqlErr.Number == mysqlLockWaitTimeoutErrNo)
+}
+
+// IsUndefinedTableError implements Dialect.
+func (MySQLDialect) IsUndefinedTableError(err error) bool {
+	mysqlErr, ok := err.(*mysqldriver.MySQLError)
+	return ok && mysqlErr.Number == mysqlUndefinedTableErrNo
+}
+
+// ReadOnlyStatements implements Dialect. MySQL has no follower reads, so
+// asOfSystemTime is ignored beyond making the transaction read-only.
+func (MySQLDialect) ReadOnlyStatements(asOfSystemTime time.Duration) []string {
+	return []string{"SET TRANSACTION READ ONLY"}
+}
@@ -0,0 +1,90 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ellcrys/gorm"
+	"github.com/lib/pq"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// postgresSQLStateSerializationFailure is the SQLSTATE vanilla Postgres
+// returns for a serializable-isolation conflict (the same code CockroachDB
+// uses, but without CockroachDB's additional restart-transaction message).
+const postgresSQLStateSerializationFailure = "40001"
+
+// postgresUndefinedTable is the SQLSTATE Postgres returns when a query
+// references a table that doesn't exist yet.
+const postgresUndefinedTable = "42P01"
+
+// PostgresDialect implements Dialect for vanilla PostgreSQL.
+type PostgresDialect struct{}
+
+// Name implements Dialect.
+func (PostgresDialect) Name() string { return "postgres" }
+
+// Open implements Dialect.
+func (PostgresDialect) Open(connStr string) (*gorm.DB, error) {
+	return gorm.Open("postgres", connStr)
+}
+
+// QuoteIdent implements Dialect.
+func (PostgresDialect) QuoteIdent(s string) string {
+	return fmt.Sprintf(`"%s"`, s)
+}
+
+// SupportsILike implements Dialect.
+func (PostgresDialect) SupportsILike() bool { return true }
+
+// TruncateSQL implements Dialect.
+func (PostgresDialect) TruncateSQL(table string) string {
+	return fmt.Sprintf("TRUNCATE %s", table)
+}
+
+// Now implements Dialect.
+func (PostgresDialect) Now() string { return "now()" }
+
+// OnConflictUpsert implements Dialect.
+func (PostgresDialect) OnConflictUpsert(conflictCol string) string {
+	return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", conflictCol)
+}
+
+// CreateSchemaSQL implements Dialect. Postgres needs no adjustment beyond
+// what gorm's AutoMigrate already applies.
+func (PostgresDialect) CreateSchemaSQL(model *ObjectModel) []string { return nil }
+
+// LockSentinelRow implements Dialect.
+func (PostgresDialect) LockSentinelRow(tx *gorm.DB, version int) error {
+	return tx.Exec("SELECT version FROM schema_meta WHERE version = ? FOR UPDATE", version).Error
+}
+
+// BulkInsert implements Dialect.
+func (PostgresDialect) BulkInsert(db *gorm.DB, rows []interface{}) error {
+	return loopInsert(db, rows)
+}
+
+// IsRetryable implements Dialect. It reports whether err represents a
+// Postgres serializable-isolation conflict.
+func (PostgresDialect) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	pqErr, ok := err.(*pq.Error)
+	return ok && string(pqErr.Code) == postgresSQLStateSerializationFailure
+}
+
+// IsUndefinedTableError implements Dialect.
+func (PostgresDialect) IsUndefinedTableError(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && string(pqErr.Code) == postgresUndefinedTable
+}
+
+// ReadOnlyStatements implements Dialect. Vanilla Postgres has no follower
+// reads, so asOfSystemTime is ignored; the transaction is made read-only
+// under REPEATABLE READ, Postgres's snapshot isolation level, so that every
+// statement in it reads the same snapshot.
+func (PostgresDialect) ReadOnlyStatements(asOfSystemTime time.Duration) []string {
+	return []string{"SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY"}
+}
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ellcrys/gorm"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// sqliteBusyMessage is the substring go-sqlite3 includes in the error
+// returned when a statement conflicts with another transaction holding the
+// database lock (SQLITE_BUSY/SQLITE_LOCKED).
+const sqliteBusyMessage = "database is locked"
+
+// sqliteNoSuchTableMessage is the substring go-sqlite3 includes in the
+// error returned when a query references a table that doesn't exist yet;
+// go-sqlite3 doesn't export a typed error for this the way lib/pq does.
+const sqliteNoSuchTableMessage = "no such table"
+
+// SQLiteDialect implements Dialect for SQLite.
+type SQLiteDialect struct{}
+
+// Name implements Dialect.
+func (SQLiteDialect) Name() string { return "sqlite3" }
+
+// Open implements Dialect.
+func (SQLiteDialect) Open(connStr string) (*gorm.DB, error) {
+	return gorm.Open("sqlite3", connStr)
+}
+
+// QuoteIdent implements Dialect.
+func (SQLiteDialect) QuoteIdent(s string) string {
+	return fmt.Sprintf(`"%s"`, s)
+}
+
+// SupportsILike implements Dialect. SQLite's LIKE is already
+// case-insensitive for ASCII, but not for the rest of Unicode, so callers
+// must fall back to LOWER(col) LIKE LOWER(?) for a consistent result.
+func (SQLiteDialect) SupportsILike() bool { return false }
+
+// TruncateSQL implements Dialect. SQLite has no TRUNCATE statement.
+func (SQLiteDialect) TruncateSQL(table string) string {
+	return fmt.Sprintf("DELETE FROM %s", table)
+}
+
+// Now implements Dialect.
+func (SQLiteDialect) Now() string { return "CURRENT_TIMESTAMP" }
+
+// OnConflictUpsert implements Dialect.
+func (SQLiteDialect) OnConflictUpsert(conflictCol string) string {
+	return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", conflictCol)
+}
+
+// CreateSchemaSQL implements Dialect. SQLite needs no adjustment beyond
+// what gorm's AutoMigrate already applies.
+func (SQLiteDialect) CreateSchemaSQL(model *ObjectModel) []string { return nil }
+
+// LockSentinelRow implements Dialect. SQLite has no SELECT ... FOR UPDATE,
+// and no need for one: the driver already serializes all writers to a
+// given database file, so there is nothing left for this to lock.
+func (SQLiteDialect) LockSentinelRow(tx *gorm.DB, version int) error { return nil }
+
+// BulkInsert implements Dialect.
+func (SQLiteDialect) BulkInsert(db *gorm.DB, rows []interface{}) error {
+	return loopInsert(db, rows)
+}
+
+// IsRetryable implements Dialect. It reports whether err represents
+// SQLite's database-locked error, which a retry from a fresh transaction
+// can resolve once the other writer finishes.
+func (SQLiteDialect) IsRetryable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), sqliteBusyMessage)
+}
+
+// IsUndefinedTableError implements Dialect.
+func (SQLiteDialect) IsUndefinedTableError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), sqliteNoSuchTableMessage)
+}
+
+// ReadOnlyStatements implements Dialect. SQLite has no SET TRANSACTION
+// statement; asOfSystemTime is ignored, and write-blocking is left to the
+// readOnly flag the caller sets on the returned handle.
+func (SQLiteDialect) ReadOnlyStatements(asOfSystemTime time.Duration) []string { return nil }
@@ -0,0 +1,211 @@
+// Package patchain defines the storage-agnostic contract used by the rest of
+// the system to persist and query chained objects. Concrete backends (such as
+// cockroach.DB) implement the DB interface declared here.
+package patchain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by GetLast when no record matches the supplied
+// criteria. GetAll and Count report no match as an empty result or a zero
+// count instead.
+var ErrNotFound = errors.New("not found")
+
+// CommitFunc commits the transaction it is bound to.
+type CommitFunc func() error
+
+// RollbackFunc rolls back the transaction it is bound to.
+type RollbackFunc func() error
+
+// TxFunc is the signature of the callback passed to DB.TransactWithDB. It
+// receives a DB handle scoped to the transaction along with explicit
+// commit/rollback helpers so the caller can decide when to finalize the
+// transaction.
+type TxFunc func(db DB, commit CommitFunc, rollback RollbackFunc) error
+
+// Expr is a raw, parameterized query expression. When set on QueryParams, it
+// is used as the query condition in place of the example object's fields;
+// the other QueryParams modifiers are still applied alongside it.
+type Expr struct {
+	Expr string
+	Args []interface{}
+}
+
+// QueryParams holds the set of generic query modifiers supported by backends
+// that implement DB. It is meant to be embedded in model structs (such as
+// tables.Object) so a single value can serve both as a query-by-example
+// object and as a holder for non-field query options.
+type QueryParams struct {
+	KeyStartsWith string
+	OrderBy       string
+	Limit         int
+	Expr          Expr
+	Filters       []FieldFilter
+}
+
+// FieldFilter describes a single, parameterized condition to apply to a
+// field when building a query, beyond what an example-object query can
+// express (e.g. comparisons, pattern matches, set membership).
+type FieldFilter struct {
+	Field string
+	Op    FilterOp
+	Value interface{}
+}
+
+// FilterOp identifies the comparison a FieldFilter applies. The set mirrors
+// Beego ORM's field lookup operators.
+type FilterOp string
+
+// Supported FieldFilter operators.
+const (
+	FilterExact       FilterOp = "exact"
+	FilterIExact      FilterOp = "iexact"
+	FilterContains    FilterOp = "contains"
+	FilterIContains   FilterOp = "icontains"
+	FilterStartsWith  FilterOp = "startswith"
+	FilterIStartsWith FilterOp = "istartswith"
+	FilterEndsWith    FilterOp = "endswith"
+	FilterIEndsWith   FilterOp = "iendswith"
+	FilterGT          FilterOp = "gt"
+	FilterGTE         FilterOp = "gte"
+	FilterLT          FilterOp = "lt"
+	FilterLTE         FilterOp = "lte"
+	FilterIn          FilterOp = "in"
+	FilterIsNull      FilterOp = "isnull"
+	FilterBetween     FilterOp = "between"
+)
+
+// GetQueryParams returns the embedded QueryParams. Models that embed
+// QueryParams can implement this to satisfy the QueryParamsGetter interface.
+type QueryParamsGetter interface {
+	GetQueryParams() *QueryParams
+}
+
+// Option is implemented by values that customize the behaviour of a DB
+// operation (e.g. running it against an existing transaction).
+type Option interface {
+	GetName() string
+}
+
+// UseDBOption instructs a DB method to run its operation against an
+// already-open DB/transaction handle instead of the receiver's own
+// connection. Finish records whether the supplied DB should be committed (or
+// rolled back, on error) once the operation completes; Create, CreateBulk
+// and UpdatePeerHash do not act on it, and leave finishing the supplied DB
+// to the caller.
+type UseDBOption struct {
+	DB     DB
+	Finish bool
+}
+
+// GetName implements Option.
+func (o *UseDBOption) GetName() string { return "UseDBOption" }
+
+// RetryOnConflict instructs TransactWithDB to automatically retry the
+// transaction (with exponential backoff) when it fails with a retryable
+// serialization conflict, instead of surfacing the error to the caller.
+type RetryOnConflict struct {
+	MaxAttempts int
+}
+
+// GetName implements Option.
+func (o *RetryOnConflict) GetName() string { return "RetryOnConflict" }
+
+// ReadOnlyOption configures a BeginReadOnly snapshot transaction. A zero
+// AsOfSystemTime reads as of now; a positive value trades a bounded amount
+// of staleness for a read that doesn't contend with concurrent writers
+// (CockroachDB's follower reads).
+type ReadOnlyOption struct {
+	AsOfSystemTime time.Duration
+}
+
+// GetName implements Option.
+func (o *ReadOnlyOption) GetName() string { return "ReadOnlyOption" }
+
+// ErrReadOnly is returned by Create, CreateBulk and UpdatePeerHash when
+// called against a DB handle obtained from BeginReadOnly.
+var ErrReadOnly = errors.New("cannot write: database handle is read-only")
+
+// DB is the interface implemented by all storage backends. It covers basic
+// CRUD, transaction management and the generic query surface used by the
+// rest of the codebase.
+type DB interface {
+
+	// Connect opens the underlying connection pool.
+	Connect(maxOpenConns, maxIdleConns int) error
+
+	// GetConn returns the underlying, driver-specific connection object.
+	GetConn() interface{}
+
+	// SetConn replaces the underlying connection object.
+	SetConn(conn interface{}) error
+
+	// NewDB returns a new, unconnected DB sharing the receiver's
+	// configuration.
+	NewDB() DB
+
+	// NoLogging disables query logging.
+	NoLogging()
+
+	// CreateTables creates all tables known to the backend.
+	CreateTables() error
+
+	// GetValidObjectFields returns the set of column names that may be
+	// referenced in a query (i.e. not blacklisted).
+	GetValidObjectFields() []string
+
+	// Create persists a new object.
+	Create(obj interface{}, opts ...Option) error
+
+	// CreateBulk persists multiple new objects, batching the inserts as far
+	// as the backend supports (possibly one statement per object).
+	CreateBulk(objs []interface{}, opts ...Option) error
+
+	// Begin starts a new transaction and returns a DB handle bound to it.
+	Begin() DB
+
+	// BeginReadOnly starts a read-only, snapshot-isolated transaction. It
+	// returns an error, instead of a handle, if the backend rejected the
+	// statements that establish the read-only/staleness guarantee — callers
+	// must not treat a returned handle as read-only unless err is nil.
+	// Create, CreateBulk and UpdatePeerHash called against the returned
+	// handle (or routed to it via UseDBOption) fail with ErrReadOnly.
+	BeginReadOnly(opts ...*ReadOnlyOption) (DB, error)
+
+	// Commit commits the transaction the receiver is bound to.
+	Commit() error
+
+	// Rollback rolls back the transaction the receiver is bound to.
+	Rollback() error
+
+	// TransactWithDB runs fn within the transaction already open on dbTx. If
+	// finishTx is true, the transaction is committed on a nil error or
+	// rolled back otherwise, unless fn already called commit/rollback
+	// itself. Passing a RetryOnConflict option causes the whole transaction
+	// to be re-run (on a fresh Begin) when it fails with a retryable
+	// serialization conflict.
+	TransactWithDB(dbTx DB, finishTx bool, fn TxFunc, opts ...Option) error
+
+	// RunInNewTxn opens a new transaction, runs fn against it and commits.
+	// When retryable is true, a transaction that fails (or fails to commit)
+	// with a retryable serialization conflict is rolled back and retried
+	// with an exponential backoff, up to a backend-defined max attempts.
+	RunInNewTxn(retryable bool, fn func(db DB) error) error
+
+	// GetLast populates dst with the last object matching the example query
+	// object, returning ErrNotFound if nothing matched.
+	GetLast(query, dst interface{}, opts ...Option) error
+
+	// GetAll populates dst with all objects matching the example query
+	// object.
+	GetAll(query, dst interface{}, opts ...Option) error
+
+	// Count sets dst to the number of objects matching the example query
+	// object.
+	Count(query interface{}, dst *int64, opts ...Option) error
+
+	// UpdatePeerHash sets the peer hash of the object matching query.
+	UpdatePeerHash(query interface{}, peerHash string, opts ...Option) error
+}